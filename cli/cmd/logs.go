@@ -2,21 +2,59 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/spf13/cobra"
 	"github.com/ttacon/chalk"
 	"k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
+const (
+	outputText = "text"
+	outputJSON = "json"
+
+	// initialStreamBackoff and maxStreamBackoff bound the delay between
+	// reconnect attempts after a container's log stream ends or fails, so a
+	// crash-looping container doesn't spin us into a busy retry loop.
+	initialStreamBackoff = 500 * time.Millisecond
+	maxStreamBackoff     = 30 * time.Second
+	maxStreamAttempts    = 10
+
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// levelSeverity ranks the recognized --level values, "at or above" style.
+var levelSeverity = map[string]int{
+	levelInfo:  0,
+	levelWarn:  1,
+	levelError: 2,
+}
+
+// levelPattern matches both the controller's logrus-style `level=warning`
+// output and the proxy's tracing-style `WARN`/`ERROR` output.
+var levelPattern = regexp.MustCompile(`(?i)level=(info|warn(?:ing)?|error)\b|\b(INFO|WARN|ERROR)\b`)
+
 type logFilter struct {
 	targetPod           v1.Pod
 	targetContainerName string
@@ -27,9 +65,28 @@ type logCmdOpts struct {
 	k8sClient        *http.Client
 	controlPlanePods *v1.PodList
 	clientset        *kubernetes.Clientset
+	namespace        string
+	selector         string
+	podLogOptions    v1.PodLogOptions
+	output           string
+	grepRe           *regexp.Regexp
+	grepVRe          *regexp.Regexp
+	level            string
+	containerFilter  string
 	logFilter
 }
 
+// logLine is a single line read from a container's log stream, carrying
+// enough provenance to be rendered either as a colorized text prefix or as
+// a structured JSON object.
+type logLine struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Namespace string `json:"namespace"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Message   string `json:"message"`
+}
+
 type ColorPicker struct {
 	m               map[string]chalk.Color
 	mu              sync.Mutex
@@ -68,7 +125,50 @@ func newColorPicker() *ColorPicker {
 	}
 }
 
-func newLogOptions(args []string, containerFilter, kubeconfigPath, kubeContext string) (*logCmdOpts, error) {
+func newLogOptions(args []string, containerFilter, selector, namespace string, sinceSeconds int64, sinceTime string, tailLines int64, timestamps, previous bool, output, grep, grepV, level, kubeconfigPath, kubeContext string) (*logCmdOpts, error) {
+	if namespace == "" {
+		namespace = controlPlaneNamespace
+	}
+
+	if output != outputText && output != outputJSON {
+		return nil, fmt.Errorf("invalid output format %q, must be one of: %s, %s", output, outputText, outputJSON)
+	}
+
+	if level != "" {
+		if _, ok := levelSeverity[level]; !ok {
+			return nil, fmt.Errorf("invalid level %q, must be one of: %s, %s, %s", level, levelInfo, levelWarn, levelError)
+		}
+	}
+
+	var grepRe, grepVRe *regexp.Regexp
+	if grep != "" {
+		re, err := regexp.Compile(grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep regexp %q: %s", grep, err)
+		}
+		grepRe = re
+	}
+	if grepV != "" {
+		re, err := regexp.Compile(grepV)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep-v regexp %q: %s", grepV, err)
+		}
+		grepVRe = re
+	}
+
+	// Parse eagerly so a malformed selector is reported before we ever talk
+	// to the API server.
+	if selector != "" {
+		if _, err := labels.Parse(selector); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %s", selector, err)
+		}
+	}
+
+	podLogOptions, err := buildPodLogOptions(sinceSeconds, sinceTime, tailLines, timestamps, previous)
+	if err != nil {
+		return nil, err
+	}
+
 	kubeAPI, err := k8s.NewAPI(kubeconfigPath, kubeContext)
 	if err != nil {
 		return nil, err
@@ -86,10 +186,13 @@ func newLogOptions(args []string, containerFilter, kubeconfigPath, kubeContext s
 
 	controlPlanePods, err := clientset.
 		CoreV1().
-		Pods(controlPlaneNamespace).
-		List(meta_v1.ListOptions{})
+		Pods(namespace).
+		List(meta_v1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
 
-	filterOpts, err := validateArgs(args, controlPlanePods, containerFilter)
+	filterOpts, err := validateArgs(args, controlPlanePods, containerFilter, selector)
 	if err != nil {
 		return nil, err
 	}
@@ -99,89 +202,440 @@ func newLogOptions(args []string, containerFilter, kubeconfigPath, kubeContext s
 		client,
 		controlPlanePods,
 		clientset,
+		namespace,
+		selector,
+		podLogOptions,
+		output,
+		grepRe,
+		grepVRe,
+		level,
+		containerFilter,
 		filterOpts,
 	}, nil
 }
 
+// buildPodLogOptions translates the logs command's flags into the
+// PodLogOptions Kubernetes expects, matching kubectl logs' handling of
+// --since-seconds/--since-time (mutually exclusive).
+func buildPodLogOptions(sinceSeconds int64, sinceTime string, tailLines int64, timestamps, previous bool) (v1.PodLogOptions, error) {
+	if sinceSeconds != 0 && sinceTime != "" {
+		return v1.PodLogOptions{}, errors.New("only one of --since-seconds or --since-time may be specified")
+	}
+
+	opts := v1.PodLogOptions{
+		// A previous container's log file is already closed and will never
+		// grow, so following it would just hang forever instead of exiting
+		// once the crash logs have been printed.
+		Follow:     !previous,
+		Timestamps: timestamps,
+		Previous:   previous,
+	}
+
+	if sinceSeconds != 0 {
+		opts.SinceSeconds = &sinceSeconds
+	}
+
+	if sinceTime != "" {
+		t, err := time.Parse(time.RFC3339, sinceTime)
+		if err != nil {
+			return v1.PodLogOptions{}, fmt.Errorf("invalid --since-time %q: %s", sinceTime, err)
+		}
+		opts.SinceTime = &meta_v1.Time{Time: t}
+	}
+
+	if tailLines != 0 {
+		opts.TailLines = &tailLines
+	}
+
+	return opts, nil
+}
+
 func newCmdLogs() *cobra.Command {
 
 	var containerFilter string
+	var selector string
+	var namespace string
+	var sinceSeconds int64
+	var sinceTime string
+	var tailLines int64
+	var timestamps bool
+	var previous bool
+	var output string
+	var grep string
+	var grepV string
+	var level string
 
 	cmd := &cobra.Command{
 		Use:   "logs (COMPONENT) [flags]",
 		Short: "Prints logs for controller components",
 		Long:  `Prints logs for controller components`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts, err := newLogOptions(args, containerFilter, kubeconfigPath, kubeContext)
+			opts, err := newLogOptions(args, containerFilter, selector, namespace, sinceSeconds, sinceTime, tailLines, timestamps, previous, output, grep, grepV, level, kubeconfigPath, kubeContext)
 
 			if err != nil {
 				return err
 			}
 
-			return runLogOutput(os.Stdout, opts)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigCh
+				cancel()
+			}()
+
+			return runLogOutput(ctx, os.Stdout, opts)
 		},
 	}
 
 	cmd.PersistentFlags().StringVarP(&containerFilter, "container", "c", containerFilter, "Filters log lines by provided container name")
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "l", selector, "Selector (label query) to filter pods, supports '=', '==', and '!=' (e.g. -l key1=value1,key2=value2)")
+	cmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", namespace, "Namespace to tail pods from (default: the control plane namespace)")
+	cmd.PersistentFlags().Int64Var(&sinceSeconds, "since-seconds", 0, "Only return logs newer than this many seconds")
+	cmd.PersistentFlags().StringVar(&sinceTime, "since-time", "", "Only return logs after a specific date (RFC3339)")
+	cmd.PersistentFlags().Int64Var(&tailLines, "tail", 0, "Number of most recent log lines to show per container; 0 shows all")
+	cmd.PersistentFlags().BoolVar(&timestamps, "timestamps", false, "Include timestamps on each line")
+	cmd.PersistentFlags().BoolVarP(&previous, "previous", "p", false, "Print the logs from the previous instance of each container, e.g. after a crash")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", outputText, "Output format; one of: text, json")
+	cmd.PersistentFlags().StringVar(&grep, "grep", "", "Only show lines matching this regexp")
+	cmd.PersistentFlags().StringVar(&grepV, "grep-v", "", "Only show lines NOT matching this regexp")
+	cmd.PersistentFlags().StringVar(&level, "level", "", "Only show lines at or above this severity; one of: info, warn, error")
 
 	return cmd
 }
 
-func runLogOutput(writer io.Writer, opts *logCmdOpts) error {
+// runLogOutput fans logs in from every targeted pod/container until ctx is
+// canceled, returning nil. When a single pod was named and every stream
+// terminates on its own instead, it returns a non-nil error. When no pod was
+// named (pods are tailed as the informer finds them), streams ending does not
+// end the command: only ctx cancellation or a write error returns.
+func runLogOutput(ctx context.Context, writer io.Writer, opts *logCmdOpts) error {
 
-	lineRead := make(chan string)
+	lineRead := make(chan logLine)
+	errCh := make(chan error)
+	var wg sync.WaitGroup
 
-	colorPicker := newColorPicker()
-	if opts.targetPod.Name == "" && opts.targetContainerName == "" {
+	tail := func(pod, container string) {
+		wg.Add(1)
+		go streamContainerLogs(ctx, &wg, opts, pod, container, lineRead, errCh)
+	}
+
+	watching := opts.targetPod.Name == "" && opts.targetContainerName == ""
+
+	if watching {
+		seen := map[string]bool{}
 		for _, pod := range opts.controlPlanePods.Items {
+			seen[pod.Name] = true
 			for _, container := range pod.Spec.Containers {
-				go func(p, c string) {
-
-					stream, err := opts.clientset.
-						CoreV1().
-						Pods(controlPlaneNamespace).
-						GetLogs(p, &v1.PodLogOptions{Container: c, Follow: true}).
-						Stream()
-
-					if err != nil {
-						return
-					}
-
-					defer stream.Close()
-
-					bufReader := bufio.NewReader(stream)
-					bytes := []byte{}
-					loglineID := fmt.Sprintf("[%s %s]", p, c)
-
-					for {
-						bytes, err = bufReader.ReadBytes('\n')
-						if err != nil {
-							fmt.Printf("ERR: %s\n", err)
-							return
-						}
-						lineRead <- fmt.Sprintf("%s %s", colorPicker.pick(loglineID).Color(loglineID), string(bytes))
-					}
-				}(pod.Name, container.Name)
+				if opts.containerFilter != "" && opts.containerFilter != container.Name {
+					continue
+				}
+				tail(pod.Name, container.Name)
 			}
 		}
+
+		stopInformer := make(chan struct{})
+		go watchForNewPods(opts, seen, tail, stopInformer)
+		defer close(stopInformer)
+	} else {
+		tail(opts.targetPod.Name, opts.targetContainerName)
+	}
+
+	// While the informer is watching for new pods it can call tail() (and
+	// so wg.Add) at any time, so wg.Wait() must never be called in that
+	// mode: an empty initial pod list would otherwise look like "all
+	// streams terminated" before the informer ever gets a pod to tail.
+	// done is only created when a single pod was named; in watching mode
+	// it stays nil, so its case below never fires.
+	var done chan struct{}
+	if !watching {
+		done = make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
 	}
 
+	colorPicker := newColorPicker()
+	var streamErrs []error
+
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
+
 		case line := <-lineRead:
-			_, err := fmt.Fprint(writer, line)
+			var err error
+			switch opts.output {
+			case outputJSON:
+				err = writeJSONLine(writer, line)
+			default:
+				loglineID := fmt.Sprintf("[%s %s]", line.Pod, line.Container)
+				prefix := colorPicker.pick(loglineID).Color(loglineID)
+				if line.Timestamp != "" {
+					prefix = fmt.Sprintf("%s %s", prefix, line.Timestamp)
+				}
+				_, err = fmt.Fprintf(writer, "%s %s", prefix, line.Message)
+			}
 			if err != nil {
-				os.Exit(1)
+				return err
+			}
+
+		case err := <-errCh:
+			streamErrs = append(streamErrs, err)
+
+		case <-done:
+			if len(streamErrs) == 0 {
+				return errors.New("all log streams terminated")
+			}
+			return fmt.Errorf("all log streams terminated: %s", streamErrs[0])
+		}
+	}
+}
+
+// streamContainerLogs tails a single container, reconnecting with
+// exponential backoff. The attempt count restarts whenever a stream is
+// opened, so it only gives up after repeated failures to open a stream.
+func streamContainerLogs(ctx context.Context, wg *sync.WaitGroup, opts *logCmdOpts, pod, container string, lineRead chan<- logLine, errCh chan<- error) {
+	defer wg.Done()
+
+	podLogOptions := opts.podLogOptions
+	podLogOptions.Container = container
+
+	if !podLogOptions.Follow {
+		// A non-follow stream (e.g. --previous) always ends on its own once
+		// fully read; there's nothing to reconnect to, so don't loop.
+		stream, err := opts.clientset.
+			CoreV1().
+			Pods(opts.namespace).
+			GetLogs(pod, &podLogOptions).
+			Stream()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case errCh <- fmt.Errorf("[%s %s]: %s", pod, container, err):
+			}
+			return
+		}
+		defer stream.Close()
+		if err := readLines(ctx, stream, pod, container, opts, lineRead); err != nil && err != io.EOF && ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case errCh <- fmt.Errorf("[%s %s]: %s", pod, container, err):
+			}
+		}
+		return
+	}
+
+	backoff := initialStreamBackoff
+	for attempt := 0; attempt < maxStreamAttempts; attempt++ {
+		stream, err := opts.clientset.
+			CoreV1().
+			Pods(opts.namespace).
+			GetLogs(pod, &podLogOptions).
+			Stream()
+
+		if err == nil {
+			err = readLines(ctx, stream, pod, container, opts, lineRead)
+			stream.Close()
+			attempt = 0
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil || err == io.EOF {
+			backoff = initialStreamBackoff
+		} else {
+			backoff = nextBackoff(backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+	case errCh <- fmt.Errorf("[%s %s]: giving up after %d attempts", pod, container, maxStreamAttempts):
+	}
+}
+
+// readLines copies lines from stream to lineRead until ctx is canceled or
+// the stream ends, returning the error (if any) that ended it.
+func readLines(ctx context.Context, stream io.ReadCloser, pod, container string, opts *logCmdOpts, lineRead chan<- logLine) error {
+	bufReader := bufio.NewReader(stream)
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		bytes, err := bufReader.ReadBytes('\n')
+		if len(bytes) > 0 {
+			timestamp, message := splitTimestamp(string(bytes), opts.podLogOptions.Timestamps)
+
+			if lineMatchesFilters(message, opts) {
+				select {
+				case <-ctx.Done():
+					return nil
+				case lineRead <- logLine{
+					Pod:       pod,
+					Container: container,
+					Namespace: opts.namespace,
+					Timestamp: timestamp,
+					Message:   message,
+				}:
+				}
 			}
 		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// nextBackoff doubles the given backoff, capped at maxStreamBackoff.
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxStreamBackoff {
+		backoff = maxStreamBackoff
+	}
+	return backoff
+}
+
+// watchForNewPods informs on the same namespace/selector the control plane
+// pod list was built from, tailing pods created after the command started.
+func watchForNewPods(opts *logCmdOpts, seen map[string]bool, tail func(pod, container string), stopCh <-chan struct{}) {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(lo meta_v1.ListOptions) (runtime.Object, error) {
+			lo.LabelSelector = opts.selector
+			return opts.clientset.CoreV1().Pods(opts.namespace).List(lo)
+		},
+		WatchFunc: func(lo meta_v1.ListOptions) (watch.Interface, error) {
+			lo.LabelSelector = opts.selector
+			return opts.clientset.CoreV1().Pods(opts.namespace).Watch(lo)
+		},
+	}
+
+	var mu sync.Mutex
+	_, informer := cache.NewInformer(listWatch, &v1.Pod{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				return
+			}
+
+			mu.Lock()
+			alreadySeen := seen[pod.Name]
+			seen[pod.Name] = true
+			mu.Unlock()
+
+			if alreadySeen {
+				return
+			}
+
+			for _, container := range pod.Spec.Containers {
+				if opts.containerFilter != "" && opts.containerFilter != container.Name {
+					continue
+				}
+				tail(pod.Name, container.Name)
+			}
+		},
+	})
+
+	informer.Run(stopCh)
+}
+
+// lineMatchesFilters applies --grep, --grep-v, and --level to a log line.
+func lineMatchesFilters(message string, opts *logCmdOpts) bool {
+	if opts.grepRe != nil && !opts.grepRe.MatchString(message) {
+		return false
+	}
+
+	if opts.grepVRe != nil && opts.grepVRe.MatchString(message) {
+		return false
+	}
+
+	if opts.level != "" && !matchesLevel(message, opts.level) {
+		return false
+	}
+
+	return true
+}
+
+// matchesLevel reports whether message's log level is at or above minLevel.
+// Lines with no recognizable level pass through unfiltered.
+func matchesLevel(message, minLevel string) bool {
+	match := levelPattern.FindStringSubmatch(message)
+	if match == nil {
+		return true
+	}
+
+	level := strings.ToLower(match[1])
+	if level == "" {
+		level = strings.ToLower(match[2])
+	}
+	if level == "warning" {
+		level = levelWarn
 	}
 
-	return nil
+	severity, ok := levelSeverity[level]
+	if !ok {
+		return true
+	}
+
+	return severity >= levelSeverity[minLevel]
+}
+
+// splitTimestamp strips the RFC3339 timestamp Kubernetes prepends to each
+// log line when PodLogOptions.Timestamps is set, returning it separately
+// from the remaining message. When timestamps are disabled, or the prefix
+// can't be found, the line is returned unchanged as the message.
+func splitTimestamp(line string, timestampsEnabled bool) (timestamp, message string) {
+	if !timestampsEnabled {
+		return "", line
+	}
+
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 {
+		return "", line
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return "", line
+	}
+
+	return parts[0], parts[1]
 }
 
-// validateArgs returns podWithContainer if args and container name matches
-// a valid pod and a valid container within that pod
-func validateArgs(args []string, pods *v1.PodList, containerName string) (logFilter, error) {
+// writeJSONLine marshals a single logLine and writes it to writer followed
+// by a newline. Only the single consumer goroutine in runLogOutput ever
+// calls this, so concurrent streams can't interleave partial JSON objects.
+func writeJSONLine(writer io.Writer, line logLine) error {
+	line.Message = strings.TrimSuffix(line.Message, "\n")
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "%s\n", encoded)
+	return err
+}
+
+// validateArgs returns a logFilter for the pod if a positional pod name was given
+// and it (plus containerName, if any) matches a pod/container in pods. A
+// selector and a positional pod name are mutually exclusive. Without a pod
+// name, there's no single match to pin down: callers fan out to every pod
+// in `pods` instead, applying containerName themselves as a filter on that
+// fan-out.
+func validateArgs(args []string, pods *v1.PodList, containerName, selector string) (logFilter, error) {
 	if pods == nil {
 		return logFilter{}, errors.New("no pods to filter logs from")
 	}
@@ -191,12 +645,16 @@ func validateArgs(args []string, pods *v1.PodList, containerName string) (logFil
 		podName = args[0]
 	}
 
-	if podName == "" && containerName == "" {
+	if podName != "" && selector != "" {
+		return logFilter{}, errors.New("cannot use a pod name together with --selector")
+	}
+
+	if podName == "" {
 		return logFilter{}, nil
 	}
 
 	for _, pod := range pods.Items {
-		if podName == "" || podName == pod.Name {
+		if podName == pod.Name {
 			for _, container := range pod.Spec.Containers {
 				if containerName == "" || containerName == container.Name {
 					return logFilter{pod, containerName}, nil